@@ -0,0 +1,71 @@
+package httpfuzz
+
+import (
+	"context"
+	"log"
+)
+
+// BuiltinConfig selects which builtin Listeners LoadBuiltins registers.
+// Each field's zero value disables that listener.
+type BuiltinConfig struct {
+	Stdout bool
+	File   *FileListenerConfig
+	Kafka  *KafkaConfig
+	HTTP   *HTTPListenerConfig
+
+	// Delivery is applied to every builtin listener LoadBuiltins registers.
+	Delivery DeliveryConfig
+}
+
+// KafkaConfig configures the builtin KafkaListener.
+type KafkaConfig struct {
+	Brokers []string
+	Topic   string
+	Key     KafkaKeyFunc
+}
+
+// RegisterListener adds a Listener to the broker under name, the same way a
+// loaded plugin is added. It's how builtin outputs (and any other
+// in-process Listener) are combined with plugins loaded from disk.
+func (p *PluginBroker) RegisterListener(ctx context.Context, name string, l Listener, cfg DeliveryConfig) {
+	p.register(ctx, name, l, cfg, nil, nil)
+}
+
+// LoadBuiltins registers the builtin outputs enabled in cfg on the broker,
+// so they run alongside any plugins already loaded with LoadPlugins or
+// LoadRPCPlugins.
+func (p *PluginBroker) LoadBuiltins(ctx context.Context, logger *log.Logger, cfg BuiltinConfig) error {
+	if cfg.Stdout {
+		l, err := NewStdoutListener(logger)
+		if err != nil {
+			return err
+		}
+		p.RegisterListener(ctx, "stdout", l, cfg.Delivery)
+	}
+
+	if cfg.File != nil {
+		l, err := NewFileListener(logger, *cfg.File)
+		if err != nil {
+			return err
+		}
+		p.RegisterListener(ctx, "file", l, cfg.Delivery)
+	}
+
+	if cfg.Kafka != nil {
+		l, err := NewKafkaListener(logger, cfg.Kafka.Brokers, cfg.Kafka.Topic, cfg.Kafka.Key)
+		if err != nil {
+			return err
+		}
+		p.RegisterListener(ctx, "kafka", l, cfg.Delivery)
+	}
+
+	if cfg.HTTP != nil {
+		l, err := NewHTTPListener(logger, *cfg.HTTP)
+		if err != nil {
+			return err
+		}
+		p.RegisterListener(ctx, "http", l, cfg.Delivery)
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package httpfuzz
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileListenerConfig configures where FileListener writes and when it
+// rotates to a new file.
+type FileListenerConfig struct {
+	// Dir is the directory results are written into. It must already exist.
+	Dir string
+	// Prefix names each rotated file, e.g. "<prefix>-<timestamp>.jsonl".
+	Prefix string
+	// Gzip compresses each file as it's written.
+	Gzip bool
+	// MaxBytes rotates to a new file once this many bytes have been written
+	// to the current one, counted before compression when Gzip is set.
+	// Zero disables size-based rotation.
+	MaxBytes int64
+	// MaxAge rotates to a new file once the current one has been open this
+	// long. Zero disables time-based rotation.
+	MaxAge time.Duration
+}
+
+// FileListener appends newline-delimited JSON results to disk, rotating to
+// a new file by size or age so a single run doesn't produce one unbounded
+// file.
+type FileListener struct {
+	logger *log.Logger
+	cfg    FileListenerConfig
+
+	mu       sync.Mutex
+	file     *os.File
+	writer   io.Writer
+	closer   io.Closer
+	size     int64
+	openedAt time.Time
+}
+
+// NewFileListener returns a FileListener that writes under cfg.Dir.
+func NewFileListener(logger *log.Logger, cfg FileListenerConfig) (*FileListener, error) {
+	f := &FileListener{logger: logger, cfg: cfg}
+	if err := f.rotate(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Listen appends results until results is closed or ctx is canceled,
+// rotating the output file as needed.
+func (f *FileListener) Listen(ctx context.Context, results <-chan *Result) {
+loop:
+	for {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				break loop
+			}
+
+			line, err := marshalResult(result)
+			if err != nil {
+				f.logger.Printf("file listener: failed to marshal result: %s", err)
+				continue
+			}
+
+			if err := f.write(line); err != nil {
+				f.logger.Printf("file listener: failed to write result: %s", err)
+			}
+		case <-ctx.Done():
+			break loop
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closeLocked()
+}
+
+func (f *FileListener) write(line []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.needsRotationLocked() {
+		if err := f.rotateLocked(); err != nil {
+			return err
+		}
+	}
+
+	n, err := f.writer.Write(append(line, '\n'))
+	f.size += int64(n)
+	return err
+}
+
+func (f *FileListener) needsRotationLocked() bool {
+	if f.cfg.MaxBytes > 0 && f.size >= f.cfg.MaxBytes {
+		return true
+	}
+	if f.cfg.MaxAge > 0 && time.Since(f.openedAt) >= f.cfg.MaxAge {
+		return true
+	}
+	return false
+}
+
+func (f *FileListener) rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rotateLocked()
+}
+
+func (f *FileListener) rotateLocked() error {
+	f.closeLocked()
+
+	name := fmt.Sprintf("%s-%d.jsonl", f.cfg.Prefix, time.Now().UnixNano())
+	if f.cfg.Gzip {
+		name += ".gz"
+	}
+
+	file, err := os.OpenFile(filepath.Join(f.cfg.Dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	f.file = file
+	f.size = 0
+	f.openedAt = time.Now()
+
+	if f.cfg.Gzip {
+		gz := gzip.NewWriter(file)
+		f.writer = gz
+		f.closer = gz
+		return nil
+	}
+
+	f.writer = file
+	f.closer = nil
+	return nil
+}
+
+func (f *FileListener) closeLocked() {
+	if f.closer != nil {
+		f.closer.Close()
+	}
+	if f.file != nil {
+		f.file.Close()
+	}
+}
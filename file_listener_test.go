@@ -0,0 +1,48 @@
+package httpfuzz
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileListenerRotatesOnMaxBytes(t *testing.T) {
+	dir := t.TempDir()
+	logger := log.New(os.Stderr, "", 0)
+
+	f, err := NewFileListener(logger, FileListenerConfig{
+		Dir:      dir,
+		Prefix:   "results",
+		MaxBytes: 1,
+	})
+	if err != nil {
+		t.Fatalf("NewFileListener: %s", err)
+	}
+
+	results := make(chan *Result, 2)
+	results <- &Result{Payload: "one"}
+	results <- &Result{Payload: "two"}
+	close(results)
+
+	f.Listen(context.Background(), results)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %s", err)
+	}
+	if got := len(entries); got != 2 {
+		t.Fatalf("got %d output files, want 2 (one per result, rotated by MaxBytes): %v", got, entries)
+	}
+
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatalf("ReadFile(%s): %s", entry.Name(), err)
+		}
+		if len(data) == 0 {
+			t.Fatalf("%s is empty, want one JSON line", entry.Name())
+		}
+	}
+}
@@ -7,25 +7,39 @@ import (
 	"net/http"
 )
 
-// Client is a modified net/http Client that can natively handle our request and response types
+// Client is a modified net/http Client that can natively handle our request and response types.
+// Do picks a transport based on each Request's Proto hint instead of always using the transport
+// the *http.Client was constructed with.
 type Client struct {
 	*http.Client
+
+	transports *protoTransports
 }
 
-// Do wraps Go's net/http client with our Request and Response types.
+// Do wraps Go's net/http client with our Request and Response types, dispatching to the
+// transport matching req.Proto.
 func (c *Client) Do(req *Request) (*Response, error) {
-	resp, err := c.Client.Do(req.Request)
+	client := c.Client
+	if c.transports != nil {
+		client = c.transports.clientFor(req.Proto)
+	}
+
+	resp, err := client.Do(req.Request)
 	return &Response{Response: resp}, err
 }
 
 // Request is a *http.Request that allows cloning its body.
 type Request struct {
 	*http.Request
+
+	// Proto hints which transport Client.Do should use. The zero value,
+	// ProtoAuto, sends the request over HTTP/1.1 without negotiating HTTP/2.
+	Proto ProtoHint
 }
 
 // CloneBody makes a copy of a request, including its body, while leaving the original body intact.
 func (r *Request) CloneBody(ctx context.Context) (*Request, error) {
-	req := &Request{Request: r.Request.Clone(ctx)}
+	req := &Request{Request: r.Request.Clone(ctx), Proto: r.Proto}
 	if req.Body == nil {
 		return req, nil
 	}
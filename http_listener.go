@@ -0,0 +1,97 @@
+package httpfuzz
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// HTTPListenerConfig configures an HTTPListener.
+type HTTPListenerConfig struct {
+	// Webhook is the URL each Result is POSTed to.
+	Webhook string
+	// Retries is how many additional attempts are made after a failed POST.
+	Retries int
+	// Backoff is the delay before the first retry; it doubles after each one.
+	Backoff time.Duration
+}
+
+// HTTPListener POSTs each Result as JSON to a webhook, retrying with
+// exponential backoff on failure.
+type HTTPListener struct {
+	logger *log.Logger
+	cfg    HTTPListenerConfig
+	client *http.Client
+}
+
+// NewHTTPListener returns an HTTPListener that posts to cfg.Webhook.
+func NewHTTPListener(logger *log.Logger, cfg HTTPListenerConfig) (*HTTPListener, error) {
+	return &HTTPListener{
+		logger: logger,
+		cfg:    cfg,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Listen POSTs results until results is closed or ctx is canceled.
+func (h *HTTPListener) Listen(ctx context.Context, results <-chan *Result) {
+	for {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				return
+			}
+
+			body, err := marshalResult(result)
+			if err != nil {
+				h.logger.Printf("http listener: failed to marshal result: %s", err)
+				continue
+			}
+
+			if err := h.postWithRetry(ctx, body); err != nil {
+				h.logger.Printf("http listener: giving up posting result: %s", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (h *HTTPListener) postWithRetry(ctx context.Context, body []byte) error {
+	backoff := h.cfg.Backoff
+
+	var err error
+	for attempt := 0; attempt <= h.cfg.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			backoff *= 2
+		}
+
+		req, reqErr := http.NewRequestWithContext(ctx, http.MethodPost, h.cfg.Webhook, bytes.NewReader(body))
+		if reqErr != nil {
+			return reqErr
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		var resp *http.Response
+		resp, err = h.client.Do(req)
+		if err != nil {
+			continue
+		}
+
+		resp.Body.Close()
+		if resp.StatusCode < 500 {
+			return nil
+		}
+		err = fmt.Errorf("webhook returned %s", resp.Status)
+	}
+
+	return err
+}
@@ -0,0 +1,82 @@
+package httpfuzz
+
+import (
+	"context"
+	"log"
+	"strconv"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+// KafkaKeyFunc picks the partition key for a Result. HostKey and
+// StatusCodeKey cover the common cases; callers can supply their own.
+type KafkaKeyFunc func(*Result) string
+
+// HostKey keys messages by the request's host, so all results for a target
+// land on the same partition.
+func HostKey(result *Result) string {
+	if result.Request == nil {
+		return ""
+	}
+	return result.Request.URL.Host
+}
+
+// StatusCodeKey keys messages by the response status code.
+func StatusCodeKey(result *Result) string {
+	if result.Response == nil {
+		return ""
+	}
+	return strconv.Itoa(result.Response.StatusCode)
+}
+
+// KafkaListener publishes each Result to a Kafka topic.
+type KafkaListener struct {
+	logger *log.Logger
+	writer *kafka.Writer
+	key    KafkaKeyFunc
+}
+
+// NewKafkaListener returns a KafkaListener publishing to topic on the given
+// brokers. If key is nil, messages are published without a key.
+func NewKafkaListener(logger *log.Logger, brokers []string, topic string, key KafkaKeyFunc) (*KafkaListener, error) {
+	return &KafkaListener{
+		logger: logger,
+		writer: &kafka.Writer{
+			Addr:     kafka.TCP(brokers...),
+			Topic:    topic,
+			Balancer: &kafka.LeastBytes{},
+		},
+		key: key,
+	}, nil
+}
+
+// Listen publishes results until results is closed or ctx is canceled.
+func (k *KafkaListener) Listen(ctx context.Context, results <-chan *Result) {
+	defer k.writer.Close()
+
+	for {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				return
+			}
+
+			value, err := marshalResult(result)
+			if err != nil {
+				k.logger.Printf("kafka listener: failed to marshal result: %s", err)
+				continue
+			}
+
+			msg := kafka.Message{Value: value}
+			if k.key != nil {
+				msg.Key = []byte(k.key(result))
+			}
+
+			if err := k.writer.WriteMessages(ctx, msg); err != nil {
+				k.logger.Printf("kafka listener: failed to publish result: %s", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
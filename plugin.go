@@ -3,20 +3,61 @@ package httpfuzz
 import (
 	"context"
 	"log"
+	"os/exec"
 	"plugin"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"google.golang.org/grpc"
 )
 
 // Listener must be implemented by a plugin to users to hook the request - response transaction.
-// The Listen method will be run in its own goroutine, so plugins cannot block the rest of the program, however panics can take down the entire process.
+// Listen runs in its own goroutine, however a panic inside Listen can take down the entire process.
+// Whether a slow plugin holds up SendResult depends on its DeliveryPolicy: Block waits for room in
+// the plugin's channel, DropOldest and DropNewest never wait. Listen should return once results is
+// closed or ctx is canceled, whichever comes first.
 type Listener interface {
-	Listen(results <-chan *Result)
+	Listen(ctx context.Context, results <-chan *Result)
+}
+
+// DeliveryPolicy controls what PluginBroker does when a plugin's Input channel is full.
+type DeliveryPolicy int
+
+const (
+	// Block waits for room in the plugin's channel, or for the SendResult ctx to be canceled.
+	Block DeliveryPolicy = iota
+	// DropOldest discards the oldest undelivered result to make room for the new one.
+	DropOldest
+	// DropNewest discards the incoming result rather than blocking.
+	DropNewest
+)
+
+// DeliveryConfig controls how results are queued for a single plugin.
+type DeliveryConfig struct {
+	// BufferSize is the capacity of the plugin's Input channel. Zero means unbuffered, except
+	// under DropOldest, which always gets a buffer of at least one.
+	BufferSize int
+	// Policy decides what happens once the channel is full. Defaults to Block.
+	Policy DeliveryPolicy
 }
 
 type pluginInfo struct {
-	Input chan<- *Result
+	rawInput chan *Result
 	Listener
+
+	// name identifies the plugin for logging and Stats; it's the plugin path for
+	// loaded plugins and the registered name for builtin listeners.
+	name   string
+	policy DeliveryPolicy
+
+	delivered uint64
+	dropped   uint64
+
+	// cmd and conn are only set for plugins loaded by LoadRPCPlugins. They let
+	// SignalDone shut the subprocess down instead of relying on process exit.
+	cmd  *exec.Cmd
+	conn *grpc.ClientConn
 }
 
 // InitializerFunc is a go function that should be exported by a function package.
@@ -34,17 +75,25 @@ type Result struct {
 	TimeElapsed time.Duration
 }
 
+// ListenerStats reports how many results a plugin has received or dropped.
+type ListenerStats struct {
+	Name      string
+	Delivered uint64
+	Dropped   uint64
+}
+
 // PluginBroker handles sending messages to plugins.
 type PluginBroker struct {
 	plugins   []*pluginInfo
 	waitGroup sync.WaitGroup
 }
 
-// SendResult sends a *Result to all loaded plugins for further processing.
-func (p *PluginBroker) SendResult(result *Result) error {
-	for _, plugin := range p.plugins {
-		// Give each plugin its own request.
-		req, err := result.Request.CloneBody(context.Background())
+// SendResult clones result once per plugin, leaving the caller's result untouched, and delivers
+// each clone according to that plugin's DeliveryPolicy. ctx is forwarded to CloneBody and
+// consulted by Block deliveries so shutdown can cancel a send that's waiting on a full channel.
+func (p *PluginBroker) SendResult(ctx context.Context, result *Result) error {
+	for _, plg := range p.plugins {
+		req, err := result.Request.CloneBody(ctx)
 		if err != nil {
 			return err
 		}
@@ -54,17 +103,55 @@ func (p *PluginBroker) SendResult(result *Result) error {
 			return err
 		}
 
-		result.Request = req
-		result.Response = resp
-
-		plugin.Input <- result
+		p.deliver(ctx, plg, &Result{
+			Request:     req,
+			Response:    resp,
+			Payload:     result.Payload,
+			Location:    result.Location,
+			FieldName:   result.FieldName,
+			TimeElapsed: result.TimeElapsed,
+		})
 	}
 	return nil
 }
 
-func (p *PluginBroker) run(plugin *pluginInfo, results <-chan *Result) {
+// deliver queues result on plg's Input channel according to plg.policy.
+func (p *PluginBroker) deliver(ctx context.Context, plg *pluginInfo, result *Result) {
+	switch plg.policy {
+	case DropNewest:
+		select {
+		case plg.rawInput <- result:
+			atomic.AddUint64(&plg.delivered, 1)
+		default:
+			atomic.AddUint64(&plg.dropped, 1)
+		}
+	case DropOldest:
+		for {
+			select {
+			case plg.rawInput <- result:
+				atomic.AddUint64(&plg.delivered, 1)
+				return
+			default:
+			}
+
+			select {
+			case <-plg.rawInput:
+				atomic.AddUint64(&plg.dropped, 1)
+			default:
+			}
+		}
+	default: // Block
+		select {
+		case plg.rawInput <- result:
+			atomic.AddUint64(&plg.delivered, 1)
+		case <-ctx.Done():
+		}
+	}
+}
+
+func (p *PluginBroker) run(ctx context.Context, plg *pluginInfo) {
 	go func() {
-		plugin.Listen(results)
+		plg.Listen(ctx, plg.rawInput)
 		p.waitGroup.Done()
 	}()
 }
@@ -74,21 +161,84 @@ func (p *PluginBroker) Wait() {
 	p.waitGroup.Wait()
 }
 
-func (p *PluginBroker) add(plugin *pluginInfo) {
-	p.plugins = append(p.plugins, plugin)
+// register builds a pluginInfo around l and starts its Listen goroutine. cmd and conn are nil for
+// everything but LoadRPCPlugins.
+func (p *PluginBroker) register(ctx context.Context, name string, l Listener, cfg DeliveryConfig, cmd *exec.Cmd, conn *grpc.ClientConn) {
+	bufferSize := cfg.BufferSize
+	if cfg.Policy == DropOldest && bufferSize < 1 {
+		// deliver's DropOldest branch drops by receiving from rawInput itself; on an
+		// unbuffered channel that receive has no buffered value to take and nothing
+		// forces the plugin's Listen goroutine to be parked on a receive at the same
+		// moment, so both selects can fail every iteration and the loop spins forever.
+		// A buffer of at least one gives the drop a value to take without a rendezvous.
+		bufferSize = 1
+	}
+
+	plg := &pluginInfo{
+		rawInput: make(chan *Result, bufferSize),
+		Listener: l,
+		name:     name,
+		policy:   cfg.Policy,
+		cmd:      cmd,
+		conn:     conn,
+	}
+
+	p.plugins = append(p.plugins, plg)
 	p.waitGroup.Add(1)
+	p.run(ctx, plg)
+}
+
+// Stats reports delivery counters for every registered plugin, in load order.
+func (p *PluginBroker) Stats() []ListenerStats {
+	stats := make([]ListenerStats, len(p.plugins))
+	for i, plg := range p.plugins {
+		stats[i] = ListenerStats{
+			Name:      plg.name,
+			Delivered: atomic.LoadUint64(&plg.delivered),
+			Dropped:   atomic.LoadUint64(&plg.dropped),
+		}
+	}
+	return stats
 }
 
+// rpcShutdownTimeout is how long SignalDone waits for a subprocess plugin to
+// exit on its own before it's killed.
+const rpcShutdownTimeout = 5 * time.Second
+
 // SignalDone closes all plugin chans that are waiting on results.
 // Call only after all results have been sent.
 func (p *PluginBroker) SignalDone() {
-	for _, plugin := range p.plugins {
-		close(plugin.Input)
+	for _, plg := range p.plugins {
+		close(plg.rawInput)
+
+		if plg.cmd == nil {
+			continue
+		}
+
+		go plg.shutdownSubprocess()
+	}
+}
+
+// shutdownSubprocess waits for an RPC plugin's subprocess to exit after its
+// stream has been half-closed, killing it if it doesn't exit in time.
+func (p *pluginInfo) shutdownSubprocess() {
+	done := make(chan error, 1)
+	go func() { done <- p.cmd.Wait() }()
+
+	select {
+	case <-done:
+	case <-time.After(rpcShutdownTimeout):
+		p.cmd.Process.Kill()
+		<-done
+	}
+
+	if p.conn != nil {
+		p.conn.Close()
 	}
 }
 
 // LoadPlugins loads Plugins from binaries on the filesytem.
-func LoadPlugins(logger *log.Logger, paths []string) (*PluginBroker, error) {
+func LoadPlugins(ctx context.Context, logger *log.Logger, paths []string, cfg DeliveryConfig) (*PluginBroker, error) {
 	broker := &PluginBroker{}
 
 	for _, path := range paths {
@@ -109,15 +259,7 @@ func LoadPlugins(logger *log.Logger, paths []string) (*PluginBroker, error) {
 			return nil, err
 		}
 
-		input := make(chan *Result)
-		httpfuzzPlugin := &pluginInfo{
-			Input:    input,
-			Listener: httpfuzzListener,
-		}
-
-		// Listen for results in a goroutine for each plugin
-		broker.add(httpfuzzPlugin)
-		broker.run(httpfuzzPlugin, input)
+		broker.register(ctx, path, httpfuzzListener, cfg, nil, nil)
 	}
 
 	return broker, nil
@@ -0,0 +1,128 @@
+package httpfuzz
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type noopListener struct{}
+
+func (noopListener) Listen(ctx context.Context, results <-chan *Result) {}
+
+// capturingListener records every *Result it receives on results, in order,
+// until the channel is closed.
+type capturingListener struct {
+	results []*Result
+}
+
+func (c *capturingListener) Listen(ctx context.Context, results <-chan *Result) {
+	for result := range results {
+		c.results = append(c.results, result)
+	}
+}
+
+// blockedListener never drains results, so a plugin registered with it stays
+// full for the lifetime of the test.
+type blockedListener struct{}
+
+func (blockedListener) Listen(ctx context.Context, results <-chan *Result) {
+	<-ctx.Done()
+}
+
+func newTestResult(t *testing.T, payload string) *Result {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	rec.Body.WriteString("body")
+	resp := rec.Result()
+
+	return &Result{
+		Request:  &Request{Request: req},
+		Response: &Response{Response: resp},
+		Payload:  payload,
+	}
+}
+
+func TestSendResultClonesPerPluginAndTracksStats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	broker := &PluginBroker{}
+	fast := &capturingListener{}
+	broker.register(ctx, "fast", fast, DeliveryConfig{Policy: Block}, nil, nil)
+	broker.register(ctx, "blocked", blockedListener{}, DeliveryConfig{Policy: DropNewest, BufferSize: 1}, nil, nil)
+
+	result := newTestResult(t, "original")
+	if err := broker.SendResult(context.Background(), result); err != nil {
+		t.Fatalf("SendResult: %v", err)
+	}
+
+	// A second send for the DropNewest plugin, whose Input is already full
+	// from the first send, must be counted as dropped rather than delivered.
+	if err := broker.SendResult(context.Background(), result); err != nil {
+		t.Fatalf("SendResult: %v", err)
+	}
+
+	broker.SignalDone()
+	cancel()
+	broker.Wait()
+
+	if len(fast.results) != 2 {
+		t.Fatalf("fast plugin got %d results, want 2", len(fast.results))
+	}
+	for _, got := range fast.results {
+		if got == result {
+			t.Fatal("plugin received the caller's *Result instead of a clone")
+		}
+		if got.Request == result.Request || got.Response == result.Response {
+			t.Fatal("plugin's clone shares Request/Response with the caller's result")
+		}
+		if got.Payload != "original" {
+			t.Fatalf("clone Payload = %q, want %q", got.Payload, "original")
+		}
+	}
+	if result.Payload != "original" {
+		t.Fatalf("caller's result.Payload was mutated to %q", result.Payload)
+	}
+
+	stats := broker.Stats()
+	byName := map[string]ListenerStats{stats[0].Name: stats[0], stats[1].Name: stats[1]}
+
+	if got := byName["fast"]; got.Delivered != 2 || got.Dropped != 0 {
+		t.Fatalf("fast stats = %+v, want Delivered=2 Dropped=0", got)
+	}
+	if got := byName["blocked"]; got.Delivered != 1 || got.Dropped != 1 {
+		t.Fatalf("blocked stats = %+v, want Delivered=1 Dropped=1", got)
+	}
+}
+
+func TestRegisterClampsDropOldestBuffer(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        DeliveryConfig
+		wantBuffer int
+	}{
+		{name: "drop oldest unbuffered clamps to one", cfg: DeliveryConfig{Policy: DropOldest}, wantBuffer: 1},
+		{name: "drop oldest buffered stays as configured", cfg: DeliveryConfig{Policy: DropOldest, BufferSize: 4}, wantBuffer: 4},
+		{name: "block unbuffered stays unbuffered", cfg: DeliveryConfig{Policy: Block}, wantBuffer: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := &PluginBroker{}
+			broker.register(context.Background(), "test", noopListener{}, tt.cfg, nil, nil)
+			broker.SignalDone()
+
+			if got := cap(broker.plugins[0].rawInput); got != tt.wantBuffer {
+				t.Fatalf("rawInput buffer = %d, want %d", got, tt.wantBuffer)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package httpfuzz
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// FrameMetadata describes a single recorded request/response pair.
+type FrameMetadata struct {
+	Timestamp    time.Time `json:"timestamp"`
+	ElapsedNanos int64     `json:"elapsed_nanos"`
+	Payload      string    `json:"payload"`
+	Location     string    `json:"location"`
+	FieldName    string    `json:"field_name"`
+
+	// Scheme is the URL scheme the request was actually sent over. The raw
+	// HTTP/1.1 wire form captured alongside this metadata doesn't carry a
+	// scheme (it's not part of the wire protocol), so a replay has nowhere
+	// else to recover it from.
+	Scheme string `json:"scheme"`
+}
+
+// Frame is one record read back from a capture file: the metadata plus the
+// raw HTTP/1.1 wire bytes of the request and response.
+type Frame struct {
+	Metadata    FrameMetadata
+	RequestRaw  []byte
+	ResponseRaw []byte
+}
+
+// Recorder is a Listener that appends every Result to a capture file as a
+// length-prefixed frame: a JSON FrameMetadata header, the request's raw
+// HTTP/1.1 wire form, and the response's raw HTTP/1.1 wire form. A
+// replay.Replayer can read the file back and re-issue the requests later.
+type Recorder struct {
+	logger *log.Logger
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewRecorder returns a Recorder that appends frames to path, creating it if
+// it doesn't exist.
+func NewRecorder(logger *log.Logger, path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Recorder{logger: logger, file: file}, nil
+}
+
+// Listen writes a frame per result until results is closed or ctx is
+// canceled.
+func (r *Recorder) Listen(ctx context.Context, results <-chan *Result) {
+	defer r.file.Close()
+
+	for {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				return
+			}
+
+			if err := r.writeFrame(result); err != nil {
+				r.logger.Printf("recorder: failed to write frame: %s", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Recorder) writeFrame(result *Result) error {
+	var reqBytes, respBytes bytes.Buffer
+	if result.Request != nil {
+		if err := result.Request.Write(&reqBytes); err != nil {
+			return err
+		}
+	}
+	if result.Response != nil {
+		if err := result.Response.Write(&respBytes); err != nil {
+			return err
+		}
+	}
+
+	var scheme string
+	if result.Request != nil && result.Request.URL != nil {
+		scheme = result.Request.URL.Scheme
+	}
+
+	metadata, err := json.Marshal(FrameMetadata{
+		Timestamp:    time.Now(),
+		ElapsedNanos: result.TimeElapsed.Nanoseconds(),
+		Payload:      result.Payload,
+		Location:     result.Location,
+		FieldName:    result.FieldName,
+		Scheme:       scheme,
+	})
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w := bufio.NewWriter(r.file)
+	for _, chunk := range [][]byte{metadata, reqBytes.Bytes(), respBytes.Bytes()} {
+		if err := binary.Write(w, binary.BigEndian, uint32(len(chunk))); err != nil {
+			return err
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+// ReadFrame reads the next frame written by a Recorder from r. It returns
+// io.EOF once no more frames remain.
+func ReadFrame(r io.Reader) (*Frame, error) {
+	metadata, err := readChunk(r)
+	if err != nil {
+		return nil, err
+	}
+
+	reqBytes, err := readChunk(r)
+	if err != nil {
+		return nil, err
+	}
+
+	respBytes, err := readChunk(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var m FrameMetadata
+	if err := json.Unmarshal(metadata, &m); err != nil {
+		return nil, err
+	}
+
+	return &Frame{Metadata: m, RequestRaw: reqBytes, ResponseRaw: respBytes}, nil
+}
+
+// readChunk reads one length-prefixed chunk. The length prefix is read with
+// a plain io.Reader read so callers see io.EOF cleanly between frames.
+func readChunk(r io.Reader) ([]byte, error) {
+	var size uint32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+
+	chunk := make([]byte, size)
+	if _, err := io.ReadFull(r, chunk); err != nil {
+		return nil, err
+	}
+
+	return chunk, nil
+}
@@ -0,0 +1,146 @@
+// Package replay reads a capture file written by httpfuzz.Recorder and
+// re-issues the recorded requests, optionally filtered or rewritten,
+// feeding the new results back into a httpfuzz.PluginBroker. This lets a
+// fuzz run be recorded once in production and replayed with mutations
+// against a staging environment, without hitting the original target again.
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mjennings2400/httpfuzz"
+)
+
+// Filter rewrites and paces requests as they're replayed.
+type Filter struct {
+	// Host, if set, overrides the request's Host and URL host.
+	Host string
+	// Scheme, if set, overrides the request's URL scheme.
+	Scheme string
+	// Headers are set on every replayed request, overriding any recorded value.
+	Headers map[string]string
+	// RateMultiplier scales the delay between requests taken from their
+	// recorded timestamps. 1 replays at the original pace, 2 replays twice as
+	// fast, 0 disables pacing and replays as fast as possible.
+	RateMultiplier float64
+}
+
+func (f Filter) apply(req *http.Request) {
+	if f.Host != "" {
+		req.Host = f.Host
+		req.URL.Host = f.Host
+	}
+	if f.Scheme != "" {
+		req.URL.Scheme = f.Scheme
+	}
+	for name, value := range f.Headers {
+		req.Header.Set(name, value)
+	}
+}
+
+// Replayer re-issues requests read from a capture file through client,
+// sending the resulting Results to broker.
+type Replayer struct {
+	logger *log.Logger
+	client *httpfuzz.Client
+	broker *httpfuzz.PluginBroker
+	filter Filter
+}
+
+// NewReplayer returns a Replayer that issues requests with client and
+// forwards results to broker.
+func NewReplayer(logger *log.Logger, client *httpfuzz.Client, broker *httpfuzz.PluginBroker, filter Filter) *Replayer {
+	return &Replayer{logger: logger, client: client, broker: broker, filter: filter}
+}
+
+// Replay reads frames from r until EOF, issuing each request and sending
+// its Result to the Replayer's broker. It returns the first error other
+// than io.EOF encountered reading frames or sending results.
+func (rp *Replayer) Replay(ctx context.Context, r io.Reader) error {
+	var lastTimestamp time.Time
+
+	for {
+		frame, err := httpfuzz.ReadFrame(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read frame: %w", err)
+		}
+
+		rp.pace(ctx, lastTimestamp, frame.Metadata.Timestamp)
+		lastTimestamp = frame.Metadata.Timestamp
+
+		result, err := rp.replayFrame(ctx, frame)
+		if err != nil {
+			rp.logger.Printf("replay: failed to replay request: %s", err)
+			continue
+		}
+
+		if err := rp.broker.SendResult(ctx, result); err != nil {
+			return err
+		}
+	}
+}
+
+func (rp *Replayer) pace(ctx context.Context, last, current time.Time) {
+	if rp.filter.RateMultiplier <= 0 || last.IsZero() {
+		return
+	}
+
+	delay := time.Duration(float64(current.Sub(last)) / rp.filter.RateMultiplier)
+	if delay <= 0 {
+		return
+	}
+
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+	}
+}
+
+func (rp *Replayer) replayFrame(ctx context.Context, frame *httpfuzz.Frame) (*httpfuzz.Result, error) {
+	rawReq, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(frame.RequestRaw)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse recorded request: %w", err)
+	}
+
+	// http.ReadRequest parses the wire form used by a server: RequestURI is
+	// set and URL carries only the path. Turn it back into the client-form
+	// request http.Client.Do expects before replaying it. The wire form
+	// never carried a scheme, so fall back to what the Recorder captured
+	// the request was actually sent over, defaulting to "http" only if
+	// that's missing too (e.g. a capture file from before Scheme was
+	// recorded).
+	rawReq.RequestURI = ""
+	rawReq.URL.Scheme = frame.Metadata.Scheme
+	if rawReq.URL.Scheme == "" {
+		rawReq.URL.Scheme = "http"
+	}
+	rawReq.URL.Host = rawReq.Host
+
+	rawReq = rawReq.WithContext(ctx)
+	rp.filter.apply(rawReq)
+
+	start := time.Now()
+	resp, err := rp.client.Do(&httpfuzz.Request{Request: rawReq})
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay request: %w", err)
+	}
+
+	return &httpfuzz.Result{
+		Request:     &httpfuzz.Request{Request: rawReq},
+		Response:    resp,
+		Payload:     frame.Metadata.Payload,
+		Location:    frame.Metadata.Location,
+		FieldName:   frame.Metadata.FieldName,
+		TimeElapsed: time.Since(start),
+	}, nil
+}
@@ -0,0 +1,136 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mjennings2400/httpfuzz"
+)
+
+// collectingListener gathers every Result it's sent, for assertions.
+type collectingListener struct {
+	mu      sync.Mutex
+	results []*httpfuzz.Result
+}
+
+func (c *collectingListener) Listen(ctx context.Context, results <-chan *httpfuzz.Result) {
+	for {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				return
+			}
+			c.mu.Lock()
+			c.results = append(c.results, result)
+			c.mu.Unlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *collectingListener) snapshot() []*httpfuzz.Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*httpfuzz.Result(nil), c.results...)
+}
+
+// TestRecordReadFrameReplayRoundTrip records a real request/response pair,
+// reads the frame back with httpfuzz.ReadFrame, and confirms Replayer
+// re-issues it against the original target and forwards a Result to the
+// broker.
+func TestRecordReadFrameReplayRoundTrip(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer server.Close()
+
+	client, err := httpfuzz.NewClient(httpfuzz.ClientConfig{})
+	if err != nil {
+		t.Fatalf("NewClient: %s", err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/fuzz-me", nil)
+	if err != nil {
+		t.Fatalf("http.NewRequest: %s", err)
+	}
+
+	fuzzReq := &httpfuzz.Request{Request: req}
+	resp, err := client.Do(fuzzReq)
+	if err != nil {
+		t.Fatalf("client.Do: %s", err)
+	}
+
+	result := &httpfuzz.Result{
+		Request:     fuzzReq,
+		Response:    resp,
+		Payload:     "' OR 1=1",
+		Location:    "query",
+		FieldName:   "id",
+		TimeElapsed: time.Millisecond,
+	}
+
+	capturePath := t.TempDir() + "/capture.bin"
+	logger := log.New(os.Stderr, "", 0)
+
+	recorder, err := httpfuzz.NewRecorder(logger, capturePath)
+	if err != nil {
+		t.Fatalf("NewRecorder: %s", err)
+	}
+
+	results := make(chan *httpfuzz.Result, 1)
+	results <- result
+	close(results)
+	recorder.Listen(context.Background(), results)
+
+	captured, err := os.ReadFile(capturePath)
+	if err != nil {
+		t.Fatalf("reading capture file: %s", err)
+	}
+
+	frame, err := httpfuzz.ReadFrame(bytes.NewReader(captured))
+	if err != nil {
+		t.Fatalf("ReadFrame: %s", err)
+	}
+	if frame.Metadata.Payload != result.Payload {
+		t.Fatalf("frame payload = %q, want %q", frame.Metadata.Payload, result.Payload)
+	}
+
+	broker := &httpfuzz.PluginBroker{}
+	collector := &collectingListener{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broker.RegisterListener(ctx, "collector", collector, httpfuzz.DeliveryConfig{})
+
+	replayer := NewReplayer(logger, client, broker, Filter{})
+	if err := replayer.Replay(ctx, bytes.NewReader(captured)); err != nil {
+		t.Fatalf("Replay: %s", err)
+	}
+
+	broker.SignalDone()
+	broker.Wait()
+
+	if gotPath != "/fuzz-me" {
+		t.Fatalf("server saw path %q, want /fuzz-me", gotPath)
+	}
+
+	got := collector.snapshot()
+	if len(got) != 1 {
+		t.Fatalf("collector got %d results, want 1", len(got))
+	}
+	if got[0].Payload != result.Payload {
+		t.Fatalf("replayed result payload = %q, want %q", got[0].Payload, result.Payload)
+	}
+	if got[0].Response.StatusCode != http.StatusTeapot {
+		t.Fatalf("replayed response status = %d, want %d", got[0].Response.StatusCode, http.StatusTeapot)
+	}
+}
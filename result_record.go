@@ -0,0 +1,128 @@
+package httpfuzz
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"unicode/utf8"
+)
+
+// resultRecord is the canonical JSON schema for a Result. Every builtin
+// Listener (StdoutListener, FileListener, KafkaListener, HTTPListener)
+// serializes to this shape, so downstream tooling can consume output from
+// any of them interchangeably.
+type resultRecord struct {
+	Request       requestRecord  `json:"request"`
+	Response      responseRecord `json:"response"`
+	Payload       string         `json:"payload"`
+	Location      string         `json:"location"`
+	FieldName     string         `json:"field_name"`
+	TimeElapsedNS int64          `json:"time_elapsed_ns"`
+}
+
+type requestRecord struct {
+	Method     string              `json:"method"`
+	URL        string              `json:"url"`
+	Proto      string              `json:"proto"`
+	Headers    map[string][]string `json:"headers"`
+	Body       string              `json:"body"`
+	BodyBase64 bool                `json:"body_base64"`
+}
+
+type responseRecord struct {
+	StatusCode int                 `json:"status_code"`
+	Proto      string              `json:"proto"`
+	Headers    map[string][]string `json:"headers"`
+	Body       string              `json:"body"`
+	BodyBase64 bool                `json:"body_base64"`
+}
+
+// marshalResult renders a Result as a single line of newline-delimited JSON
+// using the shared schema builtin listeners agree on.
+func marshalResult(result *Result) ([]byte, error) {
+	req, err := encodeRequestBody(result.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := encodeResponseBody(result.Response)
+	if err != nil {
+		return nil, err
+	}
+
+	record := resultRecord{
+		Request:       req,
+		Response:      resp,
+		Payload:       result.Payload,
+		Location:      result.Location,
+		FieldName:     result.FieldName,
+		TimeElapsedNS: result.TimeElapsed.Nanoseconds(),
+	}
+
+	return json.Marshal(record)
+}
+
+func encodeRequestBody(r *Request) (requestRecord, error) {
+	if r == nil {
+		return requestRecord{}, nil
+	}
+
+	body, encoded, err := readBody(r.Body)
+	if err != nil {
+		return requestRecord{}, err
+	}
+
+	return requestRecord{
+		Method:     r.Method,
+		URL:        r.URL.String(),
+		Proto:      r.Request.Proto,
+		Headers:    map[string][]string(r.Header),
+		Body:       body,
+		BodyBase64: encoded,
+	}, nil
+}
+
+func encodeResponseBody(r *Response) (responseRecord, error) {
+	if r == nil {
+		return responseRecord{}, nil
+	}
+
+	body, encoded, err := readBody(r.Body)
+	if err != nil {
+		return responseRecord{}, err
+	}
+
+	return responseRecord{
+		StatusCode: r.StatusCode,
+		Proto:      r.Proto,
+		Headers:    map[string][]string(r.Header),
+		Body:       body,
+		BodyBase64: encoded,
+	}, nil
+}
+
+// readBody reads body and returns it as a string, base64-encoding it (via
+// json.Marshal's []byte handling) when it isn't valid UTF-8 so the record
+// stays safe to embed in a JSON document.
+func readBody(body io.Reader) (string, bool, error) {
+	if body == nil {
+		return "", false, nil
+	}
+
+	raw, err := ioutil.ReadAll(body)
+	if err != nil {
+		return "", false, err
+	}
+
+	if utf8.Valid(raw) {
+		return string(raw), false, nil
+	}
+
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return "", false, err
+	}
+
+	// json.Marshal of a []byte produces a quoted base64 string; strip the quotes.
+	return string(encoded[1 : len(encoded)-1]), true, nil
+}
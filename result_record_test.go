@@ -0,0 +1,100 @@
+package httpfuzz
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestReadBodyBase64RoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       []byte
+		wantBase64 bool
+	}{
+		{name: "utf8 text", body: []byte("hello world"), wantBase64: false},
+		{name: "invalid utf8", body: []byte{0xff, 0xfe, 0xfd}, wantBase64: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, isBase64, err := readBody(bytes.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("readBody: %s", err)
+			}
+			if isBase64 != tt.wantBase64 {
+				t.Fatalf("isBase64 = %v, want %v", isBase64, tt.wantBase64)
+			}
+
+			// The record schema only marks whether a body was base64-encoded;
+			// round-trip it through the same decoder a consumer would use to
+			// confirm the encoded form actually recovers the original bytes.
+			var decoded []byte
+			if isBase64 {
+				decoded, err = base64.StdEncoding.DecodeString(encoded)
+				if err != nil {
+					t.Fatalf("base64 decode: %s", err)
+				}
+			} else {
+				decoded = []byte(encoded)
+			}
+
+			if !bytes.Equal(decoded, tt.body) {
+				t.Fatalf("round-tripped body = %x, want %x", decoded, tt.body)
+			}
+		})
+	}
+}
+
+func TestReadBodyNil(t *testing.T) {
+	body, isBase64, err := readBody(nil)
+	if err != nil {
+		t.Fatalf("readBody(nil): %s", err)
+	}
+	if body != "" || isBase64 {
+		t.Fatalf("readBody(nil) = (%q, %v), want (\"\", false)", body, isBase64)
+	}
+}
+
+func TestMarshalResultEncodesNonUTF8BodyAsBase64(t *testing.T) {
+	rawBody := []byte{0x00, 0x01, 0x02, 0xff}
+
+	result := &Result{
+		Request: &Request{Request: newTestRequest(t, rawBody)},
+	}
+
+	line, err := marshalResult(result)
+	if err != nil {
+		t.Fatalf("marshalResult: %s", err)
+	}
+
+	var record resultRecord
+	if err := json.Unmarshal(line, &record); err != nil {
+		t.Fatalf("unmarshal record: %s", err)
+	}
+
+	if !record.Request.BodyBase64 {
+		t.Fatalf("BodyBase64 = false, want true for non-UTF8 body")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(record.Request.Body)
+	if err != nil {
+		t.Fatalf("base64 decode: %s", err)
+	}
+	if !bytes.Equal(decoded, rawBody) {
+		t.Fatalf("decoded body = %x, want %x", decoded, rawBody)
+	}
+}
+
+func newTestRequest(t *testing.T, body []byte) *http.Request {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, "http://example.com", io.NopCloser(bytes.NewReader(body)))
+	if err != nil {
+		t.Fatalf("http.NewRequest: %s", err)
+	}
+	return req
+}
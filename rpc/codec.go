@@ -0,0 +1,41 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"google.golang.org/grpc/encoding"
+)
+
+// gobCodec implements encoding.Codec for the plain structs in listener.pb.go.
+// They don't satisfy proto.Message, so grpc-go's default codec (registered
+// under the "proto" content-subtype) can't marshal them. It's registered
+// under its own "gob" content-subtype rather than under "proto" so it only
+// applies to calls that explicitly ask for it with
+// grpc.CallContentSubtype(GobContentSubtype) on the client; a *grpc.Server
+// in a process that imports this package looks the codec up by that
+// content-subtype on its own - every other grpc.ClientConn/Server in the
+// process keeps using real protobuf.
+type gobCodec struct{}
+
+// GobContentSubtype is the content-subtype a client must request with
+// grpc.CallContentSubtype to use gobCodec for a call.
+const GobContentSubtype = "gob"
+
+func (gobCodec) Name() string { return GobContentSubtype }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func init() {
+	encoding.RegisterCodec(gobCodec{})
+}
@@ -0,0 +1,44 @@
+package rpc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGobCodecRoundTrip(t *testing.T) {
+	want := &Result{
+		Request: &Request{
+			Method:  "POST",
+			Url:     "http://example.com/login",
+			Proto:   "HTTP/1.1",
+			Headers: []*Header{{Name: "Content-Type", Values: []string{"application/json"}}},
+			Body:    []byte(`{"user":"admin"}`),
+		},
+		Response:     &Response{StatusCode: 200, Proto: "HTTP/1.1"},
+		Payload:      "' OR 1=1--",
+		Location:     "body",
+		FieldName:    "user",
+		ElapsedNanos: 42,
+	}
+
+	var codec gobCodec
+	data, err := codec.Marshal(want)
+	if err != nil {
+		t.Fatalf("Marshal: %s", err)
+	}
+
+	got := new(Result)
+	if err := codec.Unmarshal(data, got); err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("round trip mismatch:\nwant %+v\ngot  %+v", want, got)
+	}
+}
+
+func TestGobCodecName(t *testing.T) {
+	if name := (gobCodec{}).Name(); name != GobContentSubtype {
+		t.Fatalf("Name() = %q, want %q", name, GobContentSubtype)
+	}
+}
@@ -0,0 +1,45 @@
+// Package rpc defines the wire types for the Listener gRPC service described
+// by ../proto/listener.proto. This file is hand-written, not protoc-gen-go
+// output: the sandbox this was written in has no protoc/protoc-gen-go and no
+// google.golang.org/protobuf runtime dependency to generate against, so the
+// structs below track the .proto message shapes by hand instead. Keep them
+// in sync with proto/listener.proto when either changes. The wire format is
+// gob, not the standard protobuf encoding; see codec.go.
+package rpc
+
+// Header carries a single HTTP header's values.
+type Header struct {
+	Name   string
+	Values []string
+}
+
+// Request is the wire form of httpfuzz.Request.
+type Request struct {
+	Method  string
+	Url     string
+	Proto   string
+	Headers []*Header
+	Body    []byte
+}
+
+// Response is the wire form of httpfuzz.Response.
+type Response struct {
+	StatusCode int32
+	Proto      string
+	Headers    []*Header
+	Body       []byte
+}
+
+// Result is the wire form of httpfuzz.Result.
+type Result struct {
+	Request      *Request
+	Response     *Response
+	Payload      string
+	Location     string
+	FieldName    string
+	ElapsedNanos int64
+}
+
+// Empty is the Stream RPC's reply; the plugin never sends one until the
+// stream is half-closed by the parent.
+type Empty struct{}
@@ -0,0 +1,114 @@
+// Hand-written client/server stubs for the Listener service described by
+// ../proto/listener.proto; see the package doc comment in listener.pb.go for
+// why this isn't protoc-gen-go-grpc output. Shaped to match what that
+// generator would produce so it's a drop-in replacement once real codegen
+// is available.
+
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// ListenerClient is the client API for the Listener service.
+type ListenerClient interface {
+	Stream(ctx context.Context, opts ...grpc.CallOption) (Listener_StreamClient, error)
+}
+
+type listenerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewListenerClient builds a ListenerClient bound to the given connection.
+func NewListenerClient(cc grpc.ClientConnInterface) ListenerClient {
+	return &listenerClient{cc}
+}
+
+func (c *listenerClient) Stream(ctx context.Context, opts ...grpc.CallOption) (Listener_StreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Listener_serviceDesc.Streams[0], "/rpc.Listener/Stream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &listenerStreamClient{stream}, nil
+}
+
+// Listener_StreamClient is the streaming handle returned by Stream.
+type Listener_StreamClient interface {
+	Send(*Result) error
+	CloseAndRecv() (*Empty, error)
+	grpc.ClientStream
+}
+
+type listenerStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *listenerStreamClient) Send(m *Result) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *listenerStreamClient) CloseAndRecv() (*Empty, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Empty)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ListenerServer is the server API for the Listener service. Plugins
+// implement this and register it with a *grpc.Server in their main().
+type ListenerServer interface {
+	Stream(Listener_StreamServer) error
+}
+
+// Listener_StreamServer is the streaming handle passed to ListenerServer.Stream.
+type Listener_StreamServer interface {
+	SendAndClose(*Empty) error
+	Recv() (*Result, error)
+	grpc.ServerStream
+}
+
+type listenerStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *listenerStreamServer) SendAndClose(m *Empty) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *listenerStreamServer) Recv() (*Result, error) {
+	m := new(Result)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RegisterListenerServer registers srv as the implementation of the Listener
+// service on s.
+func RegisterListenerServer(s *grpc.Server, srv ListenerServer) {
+	s.RegisterService(&_Listener_serviceDesc, srv)
+}
+
+func _Listener_Stream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ListenerServer).Stream(&listenerStreamServer{stream})
+}
+
+var _Listener_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "rpc.Listener",
+	HandlerType: (*ListenerServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Stream",
+			Handler:       _Listener_Stream_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "listener.proto",
+}
@@ -0,0 +1,245 @@
+package httpfuzz
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/mjennings2400/httpfuzz/rpc"
+)
+
+// magicCookieKey and magicCookieValue are set in the plugin subprocess's
+// environment so it can tell it was launched by httpfuzz and not run
+// directly by a user, the same trick hashicorp/go-plugin uses.
+const (
+	magicCookieKey   = "HTTPFUZZ_PLUGIN"
+	magicCookieValue = "a97e2bf9-f488-4c1f-9c1e-6d7f5a4b9c3e"
+
+	// protocolVersion is bumped whenever the Listener gRPC contract changes
+	// in a way old plugin binaries can't handle.
+	protocolVersion = 1
+
+	// rpcDialTimeout bounds how long startRPCPlugin waits to dial the port a
+	// plugin's handshake advertised. A plugin whose handshake names a port
+	// nothing is listening on - a bug, or a child that crashed right after
+	// printing it - would otherwise wedge grpc.DialContext's WithBlock
+	// forever and take LoadRPCPlugins down with it.
+	rpcDialTimeout = 10 * time.Second
+)
+
+// rpcListener adapts a gRPC-backed plugin subprocess to the Listener
+// interface so it can be driven by PluginBroker like any other plugin.
+type rpcListener struct {
+	logger *log.Logger
+	stream rpc.Listener_StreamClient
+}
+
+// Listen streams results to the plugin subprocess until results is closed or
+// ctx is canceled. A plugin that crashes or returns a transport error is
+// logged and dropped; it never brings down the rest of the fuzzer.
+func (r *rpcListener) Listen(ctx context.Context, results <-chan *Result) {
+	for {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				if _, err := r.stream.CloseAndRecv(); err != nil {
+					r.logger.Printf("rpc plugin did not shut down cleanly: %s", err)
+				}
+				return
+			}
+
+			if err := r.stream.Send(toProto(result)); err != nil {
+				r.logger.Printf("rpc plugin stream error, dropping plugin: %s", err)
+				// Keep reading and discarding results so a Block delivery to this
+				// plugin doesn't wait forever on a channel nobody reads.
+				for {
+					select {
+					case _, ok := <-results:
+						if !ok {
+							return
+						}
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// LoadRPCPlugins loads plugins that run as their own subprocess and
+// communicate over gRPC, rather than Go's native plugin package. Unlike
+// plugin.Open, this works on every platform Go supports (including
+// Windows) and a panic inside the plugin can't take the fuzzer down with
+// it.
+//
+// This does NOT yet deliver the "write a plugin in any language" half of
+// the original ask: the wire format is Go gob over the hand-written
+// structs in rpc/listener.pb.go (see rpc/codec.go), not real protobuf, so
+// proto/listener.proto is documentation rather than the actual contract.
+// A plugin still has to be built against this repo's rpc package. Real
+// protoc-gen-go/protoc-gen-go-grpc output would be needed before
+// proto/listener.proto alone is enough to implement a plugin in another
+// language.
+func LoadRPCPlugins(ctx context.Context, logger *log.Logger, paths []string, cfg DeliveryConfig) (*PluginBroker, error) {
+	broker := &PluginBroker{}
+
+	for _, path := range paths {
+		listener, cmd, conn, err := startRPCPlugin(ctx, logger, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start plugin %s: %w", path, err)
+		}
+
+		broker.register(ctx, path, listener, cfg, cmd, conn)
+	}
+
+	return broker, nil
+}
+
+func startRPCPlugin(ctx context.Context, logger *log.Logger, path string) (*rpcListener, *exec.Cmd, *grpc.ClientConn, error) {
+	cmd := exec.Command(path)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%s", magicCookieKey, magicCookieValue))
+	cmd.Stderr = os.Stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Read the handshake through a bufio.Reader we keep around, rather than
+	// one scoped to readHandshake: ReadString('\n') can buffer-ahead bytes
+	// past the newline, and those would be silently lost if the reader went
+	// out of scope instead of being reused to drain the rest of stdout.
+	stdoutReader := bufio.NewReader(stdout)
+	addr, err := readHandshake(stdoutReader)
+	if err != nil {
+		cmd.Process.Kill()
+		return nil, nil, nil, err
+	}
+
+	// A plugin that logs to stdout (the common default for most logging
+	// libraries) will block on write() once the OS pipe buffer fills if
+	// nothing ever reads the other end. Drain it for the life of the
+	// subprocess so that can't wedge the plugin.
+	go io.Copy(io.Discard, stdoutReader)
+
+	dialCtx, cancel := context.WithTimeout(ctx, rpcDialTimeout)
+	conn, err := grpc.DialContext(dialCtx, addr, grpc.WithInsecure(), grpc.WithBlock())
+	cancel()
+	if err != nil {
+		cmd.Process.Kill()
+		return nil, nil, nil, err
+	}
+
+	stream, err := rpc.NewListenerClient(conn).Stream(context.Background(), grpc.CallContentSubtype(rpc.GobContentSubtype))
+	if err != nil {
+		conn.Close()
+		cmd.Process.Kill()
+		return nil, nil, nil, err
+	}
+
+	return &rpcListener{logger: logger, stream: stream}, cmd, conn, nil
+}
+
+// readHandshake reads the single handshake line a plugin subprocess must
+// print to stdout before serving: "<protocol-version>|<network>|<address>".
+// It's the only thing httpfuzz parses from the child's stdout; the caller is
+// responsible for draining whatever stdout reader produced after this
+// returns so the plugin doesn't stall writing to it.
+func readHandshake(stdout *bufio.Reader) (string, error) {
+	line, err := stdout.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed reading plugin handshake: %w", err)
+	}
+
+	parts := strings.SplitN(strings.TrimSpace(line), "|", 3)
+	if len(parts) != 3 {
+		return "", fmt.Errorf("malformed plugin handshake: %q", line)
+	}
+
+	version, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("malformed plugin handshake version: %q", parts[0])
+	}
+
+	if version != protocolVersion {
+		return "", fmt.Errorf("plugin speaks protocol version %d, httpfuzz expects %d", version, protocolVersion)
+	}
+
+	return parts[2], nil
+}
+
+func toProto(result *Result) *rpc.Result {
+	return &rpc.Result{
+		Request:      toProtoRequest(result.Request),
+		Response:     toProtoResponse(result.Response),
+		Payload:      result.Payload,
+		Location:     result.Location,
+		FieldName:    result.FieldName,
+		ElapsedNanos: result.TimeElapsed.Nanoseconds(),
+	}
+}
+
+// toProtoRequest reads r's body into the outgoing message. By the time a
+// Result reaches a Listener, PluginBroker.SendResult has already given it a
+// clone dedicated to this plugin, so consuming the body here is safe.
+func toProtoRequest(r *Request) *rpc.Request {
+	if r == nil {
+		return nil
+	}
+
+	var body []byte
+	if r.Body != nil {
+		body, _ = ioutil.ReadAll(r.Body)
+	}
+
+	return &rpc.Request{
+		Method:  r.Method,
+		Url:     r.URL.String(),
+		Proto:   r.Request.Proto,
+		Headers: toProtoHeaders(r.Header),
+		Body:    body,
+	}
+}
+
+func toProtoResponse(r *Response) *rpc.Response {
+	if r == nil {
+		return nil
+	}
+
+	var body []byte
+	if r.Body != nil {
+		body, _ = ioutil.ReadAll(r.Body)
+	}
+
+	return &rpc.Response{
+		StatusCode: int32(r.StatusCode),
+		Proto:      r.Proto,
+		Headers:    toProtoHeaders(r.Header),
+		Body:       body,
+	}
+}
+
+func toProtoHeaders(header map[string][]string) []*rpc.Header {
+	headers := make([]*rpc.Header, 0, len(header))
+	for name, values := range header {
+		headers = append(headers, &rpc.Header{Name: name, Values: values})
+	}
+	return headers
+}
@@ -0,0 +1,94 @@
+package httpfuzz
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadHandshake(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		wantErr bool
+		wantNet string
+	}{
+		{name: "valid", line: "1|tcp|127.0.0.1:12345\n", wantNet: "127.0.0.1:12345"},
+		{name: "wrong version", line: "2|tcp|127.0.0.1:12345\n", wantErr: true},
+		{name: "missing field", line: "1|tcp\n", wantErr: true},
+		{name: "non-numeric version", line: "x|tcp|127.0.0.1:12345\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, err := readHandshake(bufio.NewReader(strings.NewReader(tt.line)))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("readHandshake(%q) = %q, nil; want error", tt.line, addr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("readHandshake(%q) returned unexpected error: %s", tt.line, err)
+			}
+
+			if addr != tt.wantNet {
+				t.Fatalf("readHandshake(%q) = %q, want %q", tt.line, addr, tt.wantNet)
+			}
+		})
+	}
+}
+
+// TestReadHandshakeLeavesRemainderReadable guards against a regression where
+// ReadString's internal read-ahead buffers bytes past the handshake line
+// that the caller then has no way to reach, silently dropping whatever a
+// plugin wrote to stdout right after its handshake.
+func TestReadHandshakeLeavesRemainderReadable(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("1|tcp|127.0.0.1:12345\nlog line from the plugin\n"))
+
+	if _, err := readHandshake(r); err != nil {
+		t.Fatalf("readHandshake: %s", err)
+	}
+
+	rest, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading remainder: %s", err)
+	}
+	if want := "log line from the plugin\n"; rest != want {
+		t.Fatalf("remainder = %q, want %q", rest, want)
+	}
+}
+
+// TestStartRPCPluginBoundsDialByContext guards against a regression where a
+// plugin whose handshake names a port nothing is listening on wedged
+// startRPCPlugin forever: grpc.WithBlock retries a refused connection until
+// something tells it to stop, so the caller's ctx has to be the thing that
+// does, rather than startRPCPlugin dialing with context.Background().
+func TestStartRPCPluginBoundsDialByContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plugin.sh")
+	script := "#!/bin/sh\nprintf '1|tcp|127.0.0.1:1\\n'\nsleep 5\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatalf("os.WriteFile: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, _, err := startRPCPlugin(ctx, log.New(io.Discard, "", 0), path)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("startRPCPlugin succeeded dialing a port nothing listens on, want an error")
+	}
+	if elapsed > 2*time.Second {
+		t.Fatalf("startRPCPlugin took %s to fail, want it bounded by ctx's deadline", elapsed)
+	}
+}
@@ -0,0 +1,44 @@
+package httpfuzz
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// StdoutListener prints each Result as a line of newline-delimited JSON.
+// It's the simplest builtin output; LoadBuiltins registers it only when
+// BuiltinConfig.Stdout is set.
+type StdoutListener struct {
+	logger *log.Logger
+	out    io.Writer
+}
+
+// NewStdoutListener returns a StdoutListener that writes to os.Stdout.
+func NewStdoutListener(logger *log.Logger) (*StdoutListener, error) {
+	return &StdoutListener{logger: logger, out: os.Stdout}, nil
+}
+
+// Listen prints results until results is closed or ctx is canceled.
+func (s *StdoutListener) Listen(ctx context.Context, results <-chan *Result) {
+	for {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				return
+			}
+
+			line, err := marshalResult(result)
+			if err != nil {
+				s.logger.Printf("stdout listener: failed to marshal result: %s", err)
+				continue
+			}
+
+			fmt.Fprintln(s.out, string(line))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,506 @@
+package httpfuzz
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+)
+
+// ProtoHint selects which transport Client.Do uses for a Request.
+type ProtoHint string
+
+const (
+	// ProtoAuto uses the same net/http HTTP/1.1 transport as an unpipelined
+	// ProtoHTTP1; that transport never negotiates HTTP/2.
+	ProtoAuto ProtoHint = ""
+	// ProtoHTTP1 forces HTTP/1.1.
+	ProtoHTTP1 ProtoHint = "http1"
+	// ProtoH2C forces HTTP/2 over cleartext TCP.
+	ProtoH2C ProtoHint = "h2c"
+	// ProtoH2 forces HTTP/2 over TLS.
+	ProtoH2 ProtoHint = "h2"
+	// ProtoH3 forces HTTP/3 over QUIC.
+	ProtoH3 ProtoHint = "h3"
+)
+
+// ClientConfig configures the per-protocol transports NewClient builds.
+type ClientConfig struct {
+	// InsecureSkipVerify skips TLS certificate verification, for fuzzing
+	// targets with self-signed certs.
+	InsecureSkipVerify bool
+	// DisableALPN sets an empty ALPN protocol list on the TLS config of the
+	// ProtoAuto/ProtoHTTP1 transport. That transport never opts in to HTTP/2,
+	// so its connections offer no ALPN protocols with or without this set.
+	// It has no effect on a request that explicitly forces ProtoH2 or
+	// ProtoH3 - offering no ALPN there would defeat the point of asking for
+	// that protocol by name.
+	DisableALPN bool
+	// IsolateConnections opens a new connection per request instead of
+	// reusing one per host, so a plugin observing transport-level behavior
+	// (GOAWAY, RST_STREAM) sees it in isolation rather than on a shared,
+	// possibly already-broken connection. It applies to ProtoAuto/ProtoHTTP1,
+	// ProtoH2C and ProtoH2; ProtoH3 has no exposed way to disable quic-go's
+	// RoundTripper's own per-host connection reuse, so it's ignored there.
+	// ProtoHTTP1 requests that go through the pipelining transport
+	// (PipelineDepth > 1) share one connection per host regardless.
+	IsolateConnections bool
+	// OnConn, if set, is called with every raw connection this Client dials,
+	// before TLS (for ProtoH2) or the HTTP/2 client preface (for ProtoH2C)
+	// goes out over it, and before net/http or http2.Transport has written
+	// anything of its own. That's earlier than any HTTP/2 frame exists on
+	// the wire: writing bytes here runs ahead of the TLS handshake or the
+	// mandatory h2c preface, so it can corrupt or abort the connection, not
+	// tamper with HTTP/2 framing. It's useful for raw-connection probing -
+	// sending garbage ahead of the handshake, delaying the first byte,
+	// wrapping conn to observe or throttle traffic - but not for frame-level
+	// attacks like oversized SETTINGS, bad CONTINUATION sequences or
+	// mixed-case pseudo-headers; those need a hook into the HTTP/2 transport's
+	// write path, which this Client doesn't expose yet. It applies to the
+	// TCP-based transports (ProtoAuto/ProtoHTTP1, ProtoH2C, ProtoH2); ProtoH3
+	// dials over QUIC/UDP, which has no equivalent net.Conn to hand it, so
+	// OnConn never fires for an h3 request.
+	OnConn func(net.Conn) error
+	// PipelineDepth, when greater than 1, sends up to that many ProtoHTTP1
+	// requests to the same host back to back without waiting for each
+	// response before writing the next, the way HTTP/1.1 pipelining was
+	// originally meant to be used. It only applies to the ProtoHTTP1 hint -
+	// ProtoAuto always goes through net/http's normal one-request-per-round-trip
+	// transport. Zero or one disables pipelining and routes
+	// ProtoHTTP1 through the same transport as ProtoAuto.
+	PipelineDepth int
+}
+
+// protoTransports holds one *http.Client per protocol so Client.Do can
+// dispatch per-request based on Request.Proto.
+type protoTransports struct {
+	http1 *http.Client
+	h2c   *http.Client
+	h2    *http.Client
+	h3    *http.Client
+
+	// httpPipeline, if non-nil, is used for ProtoHTTP1 instead of http1. It's
+	// only built when ClientConfig.PipelineDepth > 1.
+	httpPipeline *http.Client
+}
+
+// NewClient builds a Client able to serve HTTP/1.1, h2c, HTTP/2 and HTTP/3
+// on a per-request basis, selected by Request.Proto.
+func NewClient(cfg ClientConfig) (*Client, error) {
+	// autoTLSConfig backs the ProtoAuto/ProtoHTTP1 transport, where
+	// DisableALPN applies. forcedTLSConfig backs the transports for
+	// protocols a request explicitly asked for (ProtoH2, ProtoH3); those
+	// must keep negotiating ALPN for the server to have any way to know
+	// which protocol the connection is for, so DisableALPN never touches it.
+	autoTLSConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.DisableALPN {
+		autoTLSConfig.NextProtos = []string{}
+	}
+	forcedTLSConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	dialer := &net.Dialer{}
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil || cfg.OnConn == nil {
+			return conn, err
+		}
+
+		if err := cfg.OnConn(conn); err != nil {
+			// The net.Dialer/http.Transport.DialContext contract requires a
+			// nil conn on error; callers that get a non-nil error treat conn
+			// as never having existed and never close it themselves.
+			conn.Close()
+			return nil, err
+		}
+
+		return conn, nil
+	}
+
+	http1Transport := &http.Transport{
+		TLSClientConfig:   autoTLSConfig,
+		DisableKeepAlives: cfg.IsolateConnections,
+		DialContext:       dial,
+	}
+
+	dialTLS := func(ctx context.Context, network, addr string, tlsCfg *tls.Config) (net.Conn, error) {
+		rawConn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		// Certificate verification needs a server name. http2.Transport's own
+		// dial path fills one in; the isolated and pipelining paths don't.
+		if tlsCfg.ServerName == "" {
+			if host, _, err := net.SplitHostPort(addr); err == nil {
+				tlsCfg = tlsCfg.Clone()
+				tlsCfg.ServerName = host
+			}
+		}
+
+		tlsConn := tls.Client(rawConn, tlsCfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			rawConn.Close()
+			return nil, err
+		}
+
+		return tlsConn, nil
+	}
+
+	h2cTransport := &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dial(context.Background(), network, addr)
+		},
+	}
+
+	h2Transport := &http2.Transport{
+		TLSClientConfig: forcedTLSConfig,
+		DialTLSContext:  dialTLS,
+	}
+
+	// http2.Transport's own dial path clones whatever TLSClientConfig it's
+	// given and prepends "h2" to NextProtos before handing it to
+	// DialTLSContext, so the server has something to select via ALPN.
+	// isolatedH2Transport dials outside that path, so it has to do the same
+	// priming itself or the handshake would offer no ALPN protocols at all.
+	h2ALPNConfig := forcedTLSConfig.Clone()
+	h2ALPNConfig.NextProtos = append([]string{"h2"}, h2ALPNConfig.NextProtos...)
+
+	var h2cRoundTripper, h2RoundTripper http.RoundTripper = h2cTransport, h2Transport
+	if cfg.IsolateConnections {
+		h2cRoundTripper = &isolatedH2Transport{
+			transport: h2cTransport,
+			dial: func(ctx context.Context, addr string) (net.Conn, error) {
+				return dial(ctx, "tcp", addr)
+			},
+		}
+		h2RoundTripper = &isolatedH2Transport{
+			transport: h2Transport,
+			dial: func(ctx context.Context, addr string) (net.Conn, error) {
+				return dialTLS(ctx, "tcp", addr, h2ALPNConfig)
+			},
+		}
+	}
+
+	h3Transport := &http3.RoundTripper{TLSClientConfig: forcedTLSConfig}
+
+	transports := &protoTransports{
+		http1: &http.Client{Transport: http1Transport},
+		h2c:   &http.Client{Transport: h2cRoundTripper},
+		h2:    &http.Client{Transport: h2RoundTripper},
+		h3:    &http.Client{Transport: h3Transport},
+	}
+
+	if cfg.PipelineDepth > 1 {
+		transports.httpPipeline = &http.Client{Transport: &pipelineTransport{
+			tlsConfig: autoTLSConfig,
+			dial:      dial,
+			dialTLS:   dialTLS,
+			depth:     cfg.PipelineDepth,
+		}}
+	}
+
+	return &Client{Client: transports.http1, transports: transports}, nil
+}
+
+// clientFor returns the *http.Client matching hint, falling back to plain
+// HTTP/1.1 for ProtoAuto.
+func (t *protoTransports) clientFor(hint ProtoHint) *http.Client {
+	switch hint {
+	case ProtoHTTP1:
+		if t.httpPipeline != nil {
+			return t.httpPipeline
+		}
+		return t.http1
+	case ProtoH2C:
+		return t.h2c
+	case ProtoH2:
+		return t.h2
+	case ProtoH3:
+		return t.h3
+	default:
+		return t.http1
+	}
+}
+
+// isolatedH2Transport is an http.RoundTripper that dials, uses and closes a
+// single HTTP/2 connection per request instead of handing requests to
+// transport's own ClientConnPool. transport.NewClientConn always marks the
+// resulting ClientConn reusable unless the *http2.Transport was built by
+// wrapping an *http.Transport with DisableKeepAlives via
+// http2.ConfigureTransport - which these standalone transports aren't - so
+// going through the pool would leak one open connection per request instead
+// of the isolation ClientConfig.IsolateConnections promises. Closing the
+// connection ourselves once the response body is drained avoids that leak.
+type isolatedH2Transport struct {
+	transport *http2.Transport
+	dial      func(ctx context.Context, addr string) (net.Conn, error)
+}
+
+func (t *isolatedH2Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	conn, err := t.dial(req.Context(), h2Authority(req))
+	if err != nil {
+		return nil, err
+	}
+
+	cc, err := t.transport.NewClientConn(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	resp, err := cc.RoundTrip(req)
+	if err != nil {
+		cc.Close()
+		return nil, err
+	}
+
+	resp.Body = &closeOnBodyClose{ReadCloser: resp.Body, conn: cc}
+	return resp, nil
+}
+
+// h2Authority returns req's host:port, defaulting the port to 443 for https
+// and 80 for plain http when req's URL didn't specify one.
+func h2Authority(req *http.Request) string {
+	host, port, err := net.SplitHostPort(req.URL.Host)
+	if err != nil {
+		host = req.URL.Host
+		port = ""
+	}
+
+	if port == "" {
+		port = "443"
+		if req.URL.Scheme == "http" {
+			port = "80"
+		}
+	}
+
+	// A bracketed IPv6 literal with no port (e.g. "[::1]") fails
+	// SplitHostPort above, so host still carries its brackets here;
+	// net.JoinHostPort would add a second pair around it.
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host + ":" + port
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+// closeOnBodyClose closes conn once the response body it wraps is closed, so
+// isolatedH2Transport's one-connection-per-request dial doesn't leak.
+type closeOnBodyClose struct {
+	io.ReadCloser
+	conn *http2.ClientConn
+}
+
+func (c *closeOnBodyClose) Close() error {
+	bodyErr := c.ReadCloser.Close()
+	connErr := c.conn.Close()
+	if bodyErr != nil {
+		return bodyErr
+	}
+	return connErr
+}
+
+// pipelineTransport is an http.RoundTripper that writes up to depth requests
+// per host back to back, without waiting for each response before writing
+// the next, instead of net/http's default one-request-at-a-time behavior.
+type pipelineTransport struct {
+	tlsConfig *tls.Config
+	dial      func(ctx context.Context, network, addr string) (net.Conn, error)
+	dialTLS   func(ctx context.Context, network, addr string, tlsCfg *tls.Config) (net.Conn, error)
+	depth     int
+
+	mu    sync.Mutex
+	conns map[string]*pipelineConn
+}
+
+// RoundTrip looks up or dials the pipelineConn for req's authority and hands
+// the request to it. The dial, when one is needed, happens under t.mu rather
+// than after releasing it, so two requests racing to be first to a host
+// can't each dial their own connection and end up pipelining two requests
+// each instead of two requests on one connection.
+func (t *pipelineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	authority := h2Authority(req)
+
+	t.mu.Lock()
+	pc := t.conns[authority]
+	if pc != nil && pc.broken() != nil {
+		pc = nil
+	}
+	if pc == nil {
+		conn, err := t.dialAuthority(req, authority)
+		if err != nil {
+			t.mu.Unlock()
+			return nil, err
+		}
+
+		pc = newPipelineConn(conn, t.depth)
+		if t.conns == nil {
+			t.conns = map[string]*pipelineConn{}
+		}
+		t.conns[authority] = pc
+	}
+	t.mu.Unlock()
+
+	return pc.roundTrip(req)
+}
+
+func (t *pipelineTransport) dialAuthority(req *http.Request, authority string) (net.Conn, error) {
+	if req.URL.Scheme == "https" {
+		return t.dialTLS(req.Context(), "tcp", authority, t.tlsConfig)
+	}
+	return t.dial(req.Context(), "tcp", authority)
+}
+
+// pipelineResult is the outcome of reading one response off a pipelineConn,
+// delivered to the goroutine that wrote the matching request.
+type pipelineResult struct {
+	resp *http.Response
+	err  error
+}
+
+// pipelineConn pipelines HTTP/1.1 requests and responses over a single
+// persistent connection: writes are serialized and handed straight to the
+// wire, while a dedicated goroutine reads responses back in the same order
+// the requests were written, so no response is ever matched to the wrong
+// request. sem bounds how many requests can be outstanding, unread, at once;
+// queue carries their response channels in write order for the read loop.
+type pipelineConn struct {
+	conn net.Conn
+	bw   *bufio.Writer
+	br   *bufio.Reader
+
+	writeMu sync.Mutex
+	sem     chan struct{}
+	queue   chan chan pipelineResult
+
+	brokenMu sync.Mutex
+	err      error
+}
+
+func newPipelineConn(conn net.Conn, depth int) *pipelineConn {
+	pc := &pipelineConn{
+		conn:  conn,
+		bw:    bufio.NewWriter(conn),
+		br:    bufio.NewReader(conn),
+		sem:   make(chan struct{}, depth),
+		queue: make(chan chan pipelineResult, depth),
+	}
+	go pc.readLoop()
+	return pc
+}
+
+func (pc *pipelineConn) roundTrip(req *http.Request) (*http.Response, error) {
+	respCh := make(chan pipelineResult, 1)
+
+	pc.sem <- struct{}{}
+
+	// queue must gain respCh in exactly the order the request is written to
+	// pc.bw, so readLoop's FIFO walk of queue lines up with the FIFO order
+	// responses actually arrive on the wire; doing both under writeMu is
+	// what guarantees that. Checking broken() under the same lock as the
+	// send is what lets fail() close queue safely: once fail has the lock
+	// and sees it's the first failure, no later roundTrip can still be
+	// mid-send past this check.
+	pc.writeMu.Lock()
+	if err := pc.broken(); err != nil {
+		pc.writeMu.Unlock()
+		<-pc.sem
+		return nil, err
+	}
+	pc.queue <- respCh
+	writeErr := req.Write(pc.bw)
+	if writeErr == nil {
+		writeErr = pc.bw.Flush()
+	}
+	pc.writeMu.Unlock()
+
+	if writeErr != nil {
+		// The connection may now hold a half-written request, which would
+		// corrupt every response after it; fail the whole connection rather
+		// than let later callers read garbage.
+		pc.fail(writeErr)
+	}
+
+	result := <-respCh
+	if result.err == nil && writeErr != nil {
+		return nil, writeErr
+	}
+	return result.resp, result.err
+}
+
+// readLoop reads responses off pc in the order roundTrip wrote the matching
+// requests, handing each one to the goroutine waiting on its respCh.
+func (pc *pipelineConn) readLoop() {
+	for respCh := range pc.queue {
+		if err := pc.broken(); err != nil {
+			respCh <- pipelineResult{err: err}
+			<-pc.sem
+			continue
+		}
+
+		resp, err := http.ReadResponse(pc.br, nil)
+		if err != nil {
+			pc.fail(err)
+			respCh <- pipelineResult{err: err}
+			<-pc.sem
+			continue
+		}
+
+		// A pipelined response's body ends exactly where the next response
+		// begins on the wire, so it must be fully drained from pc.br here,
+		// before the next loop iteration starts parsing the next response -
+		// otherwise the two parses race on the same buffered reader. The
+		// caller gets a copy backed by memory instead of the live connection.
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			pc.fail(err)
+			respCh <- pipelineResult{err: err}
+			<-pc.sem
+			continue
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		respCh <- pipelineResult{resp: resp}
+		<-pc.sem
+	}
+}
+
+// fail marks pc unusable for any future request, closes its connection, and
+// closes queue so readLoop drains whatever's already queued and exits
+// instead of blocking on it forever - otherwise a pipelineConn evicted from
+// pipelineTransport.conns after going bad would leak its readLoop goroutine.
+// It takes writeMu so it can't close queue while roundTrip is mid-send to
+// it; a write or read failure on one request can't be silently ignored by
+// the requests pipelined behind it either way.
+func (pc *pipelineConn) fail(err error) {
+	pc.writeMu.Lock()
+	defer pc.writeMu.Unlock()
+
+	pc.brokenMu.Lock()
+	first := pc.err == nil
+	if first {
+		pc.err = err
+		pc.conn.Close()
+	}
+	pc.brokenMu.Unlock()
+
+	if first {
+		close(pc.queue)
+	}
+}
+
+func (pc *pipelineConn) broken() error {
+	pc.brokenMu.Lock()
+	defer pc.brokenMu.Unlock()
+	return pc.err
+}
@@ -0,0 +1,398 @@
+package httpfuzz
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// recordingRoundTripper reports its own name as an error so a test can tell
+// which transport a request was dispatched to without a real network call.
+type recordingRoundTripper struct {
+	name string
+}
+
+func (r recordingRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, recordingError(r.name)
+}
+
+type recordingError string
+
+func (e recordingError) Error() string { return string(e) }
+
+func TestClientDoDispatchesOnRequestProto(t *testing.T) {
+	transports := &protoTransports{
+		http1: &http.Client{Transport: recordingRoundTripper{name: "http1"}},
+		h2c:   &http.Client{Transport: recordingRoundTripper{name: "h2c"}},
+		h2:    &http.Client{Transport: recordingRoundTripper{name: "h2"}},
+		h3:    &http.Client{Transport: recordingRoundTripper{name: "h3"}},
+	}
+	client := &Client{Client: transports.http1, transports: transports}
+
+	tests := []struct {
+		proto ProtoHint
+		want  string
+	}{
+		{proto: ProtoAuto, want: "http1"},
+		{proto: ProtoHTTP1, want: "http1"},
+		{proto: ProtoH2C, want: "h2c"},
+		{proto: ProtoH2, want: "h2"},
+		{proto: ProtoH3, want: "h3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.proto), func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+			if err != nil {
+				t.Fatalf("http.NewRequest: %s", err)
+			}
+
+			_, err = client.Do(&Request{Request: req, Proto: tt.proto})
+			if err == nil {
+				t.Fatalf("Do(proto=%q) returned nil error, want the recording transport's marker error", tt.proto)
+			}
+
+			var marker recordingError
+			if !errors.As(err, &marker) {
+				t.Fatalf("Do(proto=%q) error %q didn't wrap a recordingError", tt.proto, err)
+			}
+			if got := string(marker); got != tt.want {
+				t.Fatalf("Do(proto=%q) used transport %q, want %q", tt.proto, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestIsolatedH2TransportDoesNotLeakConnections guards against a regression
+// where dialing a fresh connection per request for IsolateConnections never
+// closed it: that would eventually run the process out of file descriptors,
+// so many isolated requests in a row over a real HTTP/2 connection must all
+// still succeed.
+func TestIsolatedH2TransportDoesNotLeakConnections(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	client, err := NewClient(ClientConfig{InsecureSkipVerify: true, IsolateConnections: true})
+	if err != nil {
+		t.Fatalf("NewClient: %s", err)
+	}
+
+	for i := 0; i < 20; i++ {
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatalf("http.NewRequest: %s", err)
+		}
+
+		resp, err := client.Do(&Request{Request: req, Proto: ProtoH2})
+		if err != nil {
+			t.Fatalf("request %d: Do: %s", i, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want %d", i, resp.StatusCode, http.StatusOK)
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestH2Authority(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{name: "https no port", url: "https://example.com/", want: "example.com:443"},
+		{name: "http no port", url: "http://example.com/", want: "example.com:80"},
+		{name: "explicit port kept", url: "https://example.com:9000/", want: "example.com:9000"},
+		{name: "bracketed ipv6 no port", url: "https://[::1]/", want: "[::1]:443"},
+		{name: "bracketed ipv6 with port", url: "https://[::1]:9000/", want: "[::1]:9000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatalf("http.NewRequest: %s", err)
+			}
+
+			if got := h2Authority(req); got != tt.want {
+				t.Fatalf("h2Authority(%s) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPipelineTransportWritesSecondRequestBeforeFirstResponse guards the
+// actual point of PipelineDepth: a second request to the same host must
+// reach the server before the server has sent a response to the first one,
+// proving the client isn't waiting for each round trip to finish before
+// starting the next.
+func TestPipelineTransportWritesSecondRequestBeforeFirstResponse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %s", err)
+	}
+	defer ln.Close()
+
+	firstSeen := make(chan struct{})
+	secondSeen := make(chan struct{})
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		br := bufio.NewReader(conn)
+
+		req1, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+		io.Copy(io.Discard, req1.Body)
+		req1.Body.Close()
+		close(firstSeen)
+
+		req2, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+		io.Copy(io.Discard, req2.Body)
+		req2.Body.Close()
+		close(secondSeen)
+
+		fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nfirst")
+		fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Length: 6\r\n\r\nsecond")
+	}()
+
+	transport := &pipelineTransport{
+		dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+		depth: 2,
+	}
+
+	newReq := func(path string) *http.Request {
+		req, err := http.NewRequest(http.MethodGet, "http://"+ln.Addr().String()+path, nil)
+		if err != nil {
+			t.Fatalf("http.NewRequest: %s", err)
+		}
+		return req
+	}
+
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+
+	result1 := make(chan result, 1)
+	go func() {
+		resp, err := transport.RoundTrip(newReq("/1"))
+		result1 <- result{resp, err}
+	}()
+
+	select {
+	case <-firstSeen:
+	case <-time.After(2 * time.Second):
+		t.Fatal("server never saw the first request")
+	}
+
+	result2 := make(chan result, 1)
+	go func() {
+		resp, err := transport.RoundTrip(newReq("/2"))
+		result2 <- result{resp, err}
+	}()
+
+	select {
+	case <-secondSeen:
+	case <-time.After(2 * time.Second):
+		t.Fatal("second request was not written before the first response came back - pipelining didn't happen")
+	}
+
+	r1 := <-result1
+	if r1.err != nil {
+		t.Fatalf("first RoundTrip: %s", r1.err)
+	}
+	body1, _ := io.ReadAll(r1.resp.Body)
+	if string(body1) != "first" {
+		t.Fatalf("first response body = %q, want %q", body1, "first")
+	}
+
+	r2 := <-result2
+	if r2.err != nil {
+		t.Fatalf("second RoundTrip: %s", r2.err)
+	}
+	body2, _ := io.ReadAll(r2.resp.Body)
+	if string(body2) != "second" {
+		t.Fatalf("second response body = %q, want %q", body2, "second")
+	}
+}
+
+// TestPipelineTransportDepthBoundsInFlightRequests checks that a third
+// request on a connection already at PipelineDepth capacity waits to be
+// written until one of the two in-flight requests is read off, rather than
+// pipelining unboundedly.
+func TestPipelineTransportDepthBoundsInFlightRequests(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %s", err)
+	}
+	defer ln.Close()
+
+	release := make(chan struct{})
+	seen := make(chan struct{}, 3)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// Read requests as soon as they reach the wire, independently of
+		// answering them, so a request written too early shows up on seen.
+		ready := make(chan struct{}, 3)
+		go func() {
+			br := bufio.NewReader(conn)
+			for i := 0; i < 3; i++ {
+				req, err := http.ReadRequest(br)
+				if err != nil {
+					return
+				}
+				io.Copy(io.Discard, req.Body)
+				req.Body.Close()
+				seen <- struct{}{}
+				ready <- struct{}{}
+			}
+		}()
+
+		<-release
+		for i := 0; i < 3; i++ {
+			<-ready
+			fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+		}
+	}()
+
+	transport := &pipelineTransport{
+		dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+		depth: 2,
+	}
+
+	newReq := func(path string) *http.Request {
+		req, err := http.NewRequest(http.MethodGet, "http://"+ln.Addr().String()+path, nil)
+		if err != nil {
+			t.Fatalf("http.NewRequest: %s", err)
+		}
+		return req
+	}
+
+	done := make(chan struct{}, 3)
+	for _, path := range []string{"/1", "/2", "/3"} {
+		p := path
+		go func() {
+			resp, err := transport.RoundTrip(newReq(p))
+			if err == nil {
+				resp.Body.Close()
+			}
+			done <- struct{}{}
+		}()
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-seen:
+		case <-time.After(2 * time.Second):
+			t.Fatal("server never saw the first two requests")
+		}
+	}
+
+	select {
+	case <-seen:
+		t.Fatal("a third request was written while two were still in flight, depth limit wasn't enforced")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	select {
+	case <-done:
+		t.Fatal("a request completed before the server sent any response")
+	default:
+	}
+
+	close(release)
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("not all requests completed after release")
+		}
+	}
+}
+
+// TestPipelineTransportDoesNotLeakReadLoopGoroutines guards against a
+// regression where pipelineConn.readLoop blocked forever on an unclosed
+// pc.queue once pipelineTransport.RoundTrip evicted a broken pipelineConn:
+// a flaky target that closes the connection on every request would leak one
+// goroutine per request forever instead of each readLoop exiting once its
+// pipelineConn is marked broken.
+func TestPipelineTransportDoesNotLeakReadLoopGoroutines(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %s", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	transport := &pipelineTransport{
+		dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+		depth: 2,
+	}
+
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 20; i++ {
+		req, err := http.NewRequest(http.MethodGet, "http://"+ln.Addr().String()+"/", nil)
+		if err != nil {
+			t.Fatalf("http.NewRequest: %s", err)
+		}
+		// Every request against this server fails, either to write or to
+		// read a response; only the resulting readLoop cleanup is under test.
+		resp, err := transport.RoundTrip(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := runtime.NumGoroutine(); got > before {
+		t.Fatalf("goroutines after 20 failed requests = %d, want <= %d (readLoop leaked)", got, before)
+	}
+}